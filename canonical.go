@@ -0,0 +1,90 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// CanonicalHost is HTTP middleware that redirects requests whose Host header
+// doesn't match the given canonical domain (scheme, host, and optional port)
+// to the canonical equivalent, preserving the request path and query.
+//
+// domain is a full URL such as "https://www.example.com" or
+// "http://example.com:8080"; code must be an HTTP 3xx redirect status.
+// CanonicalHost panics if code is not in the 3xx range, since that is a
+// configuration error rather than something that should surface at request
+// time.
+//
+// CanonicalHost leaves CONNECT requests and requests with an empty or
+// unparsable Host header alone, since there is nothing canonical to
+// redirect to in either case. Wrap the handler with CanonicalHost before
+// MethodHandler (ie. CanonicalHost should be the outermost middleware) so
+// that a redirect happens before method dispatch is ever considered.
+func CanonicalHost(domain string, code int) func(http.Handler) http.Handler {
+	if code < 300 || code > 399 {
+		panic("handlers: CanonicalHost code must be a 3xx redirect status")
+	}
+
+	canonicalURL, err := url.Parse(domain)
+	if err != nil {
+		panic("handlers: CanonicalHost domain is not a valid URL: " + err.Error())
+	}
+
+	return func(h http.Handler) http.Handler {
+		return canonicalHost{
+			handler: h,
+			domain:  canonicalURL,
+			code:    code,
+		}
+	}
+}
+
+type canonicalHost struct {
+	handler http.Handler
+	domain  *url.URL
+	code    int
+}
+
+func (ch canonicalHost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect || r.Host == "" {
+		ch.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if u, err := url.Parse("//" + r.Host); err != nil || u.Host != r.Host {
+		ch.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if r.Host == ch.domain.Host && requestScheme(r) == ch.domain.Scheme {
+		ch.handler.ServeHTTP(w, r)
+		return
+	}
+
+	dest := *ch.domain
+	dest.Path = r.URL.Path
+	dest.RawQuery = r.URL.RawQuery
+	dest.Fragment = r.URL.Fragment
+
+	http.Redirect(w, r, dest.String(), ch.code)
+}
+
+// requestScheme reports the scheme the client actually used: "https" for a
+// TLS connection, otherwise whatever r.URL.Scheme carries (eg. set by
+// ProxyHeaders from X-Forwarded-Proto/Forwarded when behind a proxy), or
+// "http" if neither is set.
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+
+	if r.URL.Scheme != "" {
+		return r.URL.Scheme
+	}
+
+	return "http"
+}
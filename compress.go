@@ -0,0 +1,294 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const acceptEncoding string = "Accept-Encoding"
+
+type compressResponseWriter struct {
+	compressor io.Writer
+	w          http.ResponseWriter
+}
+
+func (cw *compressResponseWriter) WriteHeader(c int) {
+	cw.w.Header().Del("Content-Length")
+	cw.w.WriteHeader(c)
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	h := cw.w.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(b))
+	}
+	h.Del("Content-Length")
+
+	return cw.compressor.Write(b)
+}
+
+func (cw *compressResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(cw.compressor, r)
+}
+
+type flusher interface {
+	Flush() error
+}
+
+func (cw *compressResponseWriter) Flush() {
+	// Flush compressed data if compressor supports it.
+	if f, ok := cw.compressor.(flusher); ok {
+		f.Flush()
+	}
+	// Flush HTTP response.
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressResponseWriter) Header() http.Header {
+	return cw.w.Header()
+}
+
+// newCompressResponseWriter wraps w with compression, returning a value
+// that implements http.Hijacker and/or http.CloseNotifier only when w
+// itself does. A fixed struct implementing both unconditionally would let
+// a downstream handler's `w.(http.Hijacker)` probe succeed against a
+// writer (eg. httptest.ResponseRecorder) that never actually supports it,
+// panicking on the underlying type assertion.
+func newCompressResponseWriter(w http.ResponseWriter, compressor io.Writer) http.ResponseWriter {
+	cw := &compressResponseWriter{compressor: compressor, w: w}
+
+	_, isHijacker := w.(http.Hijacker)
+	_, isCloseNotifier := w.(http.CloseNotifier)
+
+	switch {
+	case isHijacker && isCloseNotifier:
+		return &hijackCloseNotifyCompressResponseWriter{cw}
+	case isHijacker:
+		return &hijackCompressResponseWriter{cw}
+	case isCloseNotifier:
+		return &closeNotifyCompressResponseWriter{cw}
+	default:
+		return cw
+	}
+}
+
+type hijackCompressResponseWriter struct {
+	*compressResponseWriter
+}
+
+func (cw *hijackCompressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return cw.w.(http.Hijacker).Hijack()
+}
+
+type closeNotifyCompressResponseWriter struct {
+	*compressResponseWriter
+}
+
+func (cw *closeNotifyCompressResponseWriter) CloseNotify() <-chan bool {
+	return cw.w.(http.CloseNotifier).CloseNotify()
+}
+
+type hijackCloseNotifyCompressResponseWriter struct {
+	*compressResponseWriter
+}
+
+func (cw *hijackCloseNotifyCompressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return cw.w.(http.Hijacker).Hijack()
+}
+
+func (cw *hijackCloseNotifyCompressResponseWriter) CloseNotify() <-chan bool {
+	return cw.w.(http.CloseNotifier).CloseNotify()
+}
+
+// CompressHandler gzip compresses HTTP responses for clients that support it
+// via the 'Accept-Encoding' header.
+//
+// Compressing TLS traffic may leak the page contents to an attacker if the
+// page contains user input: http://security.stackexchange.com/a/102015/12208
+func CompressHandler(h http.Handler) http.Handler {
+	return CompressHandlerLevel(h, gzip.DefaultCompression)
+}
+
+// CompressHandlerLevel gzip compresses HTTP responses for clients that
+// support it via the 'Accept-Encoding' header.
+//
+// The compression level used is fine-tuned via the level argument. Invalid
+// values fall back to gzip.DefaultCompression.
+//
+// Compressing TLS traffic may leak the page contents to an attacker if the
+// page contains user input: http://security.stackexchange.com/a/102015/12208
+func CompressHandlerLevel(h http.Handler, level int) http.Handler {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+
+	const (
+		gzipEncoding  = "gzip"
+		flateEncoding = "deflate"
+	)
+
+	gzipPool := sync.Pool{
+		New: func() interface{} {
+			gw, _ := gzip.NewWriterLevel(ioutil.Discard, level)
+			return gw
+		},
+	}
+	flatePool := sync.Pool{
+		New: func() interface{} {
+			fw, _ := flate.NewWriter(ioutil.Discard, level)
+			return fw
+		},
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", acceptEncoding)
+
+		if r.Header.Get("Upgrade") != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if w.Header().Get("Content-Encoding") != "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		encoding := selectEncoding(r.Header.Get(acceptEncoding))
+		switch encoding {
+		case gzipEncoding:
+			gw := gzipPool.Get().(*gzip.Writer)
+			defer gzipPool.Put(gw)
+			gw.Reset(w)
+			defer gw.Close()
+
+			w.Header().Set("Content-Encoding", gzipEncoding)
+			h.ServeHTTP(newCompressResponseWriter(w, gw), r)
+		case flateEncoding:
+			fw := flatePool.Get().(*flate.Writer)
+			defer flatePool.Put(fw)
+			fw.Reset(w)
+			defer fw.Close()
+
+			w.Header().Set("Content-Encoding", flateEncoding)
+			h.ServeHTTP(newCompressResponseWriter(w, fw), r)
+		default:
+			h.ServeHTTP(w, r)
+		}
+	})
+}
+
+// selectEncoding parses an Accept-Encoding header and returns "gzip",
+// "deflate", or "" if neither is acceptable to the client. gzip is preferred
+// over deflate when both are equally weighted. q-values of 0 disqualify an
+// encoding, and "*" matches any encoding not otherwise named.
+func selectEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	type candidate struct {
+		name string
+		q    float64
+	}
+
+	var gzipQ, flateQ, starQ float64 = -1, -1, -1
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name := part
+		q := 1.0
+		if idx := strings.Index(part, ";"); idx != -1 {
+			name = strings.TrimSpace(part[:idx])
+			if qv, ok := parseQValue(part[idx+1:]); ok {
+				q = qv
+			}
+		}
+
+		switch strings.ToLower(name) {
+		case "gzip":
+			gzipQ = q
+		case "deflate":
+			flateQ = q
+		case "*":
+			starQ = q
+		case "identity":
+			// identity is always acceptable but carries no preference for
+			// compression, so it is simply ignored here.
+		}
+	}
+
+	if gzipQ < 0 && starQ >= 0 {
+		gzipQ = starQ
+	}
+	if flateQ < 0 && starQ >= 0 {
+		flateQ = starQ
+	}
+
+	best := candidate{}
+	for _, c := range []candidate{{"gzip", gzipQ}, {"deflate", flateQ}} {
+		if c.q > 0 && c.q > best.q {
+			best = c
+		}
+	}
+
+	return best.name
+}
+
+// parseQValue extracts the q-value from a header parameter segment such as
+// " q=0.5". It returns ok=false if the segment isn't a recognizable q-value,
+// in which case the caller should fall back to the default weight of 1.
+func parseQValue(param string) (float64, bool) {
+	param = strings.TrimSpace(param)
+	if !strings.HasPrefix(param, "q=") {
+		return 0, false
+	}
+
+	var q float64
+	var frac float64 = 1
+	rest := param[2:]
+	seenDigit := false
+	seenDot := false
+
+	for _, r := range rest {
+		switch {
+		case r >= '0' && r <= '9':
+			seenDigit = true
+			d := float64(r - '0')
+			if !seenDot {
+				q = q*10 + d
+			} else {
+				frac /= 10
+				q += d * frac
+			}
+		case r == '.' && !seenDot:
+			seenDot = true
+		default:
+			if !seenDigit {
+				return 0, false
+			}
+		}
+	}
+
+	if !seenDigit {
+		return 0, false
+	}
+
+	return q, true
+}
@@ -0,0 +1,34 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import "testing"
+
+func TestSelectEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip, deflate", "gzip"},
+		{"gzip;q=1.0, deflate;q=1.0", "gzip"},
+		{"gzip;q=0.5, deflate;q=1.0", "deflate"},
+		{"gzip;q=0, deflate;q=0.5", "deflate"},
+		{"gzip;q=0, deflate;q=0", ""},
+		{"identity", ""},
+		{"*;q=1.0", "gzip"},
+		{"br", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			if got := selectEncoding(tt.acceptEncoding); got != tt.want {
+				t.Errorf("selectEncoding(%q) = %q, want %q", tt.acceptEncoding, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSOption is used to configure the CORS middleware created by CORS.
+type CORSOption func(*cors)
+
+type cors struct {
+	h                      http.Handler
+	allowedHeaders         []string
+	allowedMethods         []string
+	allowedOrigins         []string
+	allowedOriginValidator OriginValidator
+	exposedHeaders         []string
+	maxAge                 int
+	ignoreOptions          bool
+	allowCredentials       bool
+	optionStatusCode       int
+}
+
+// OriginValidator takes an origin string and returns whether or not that origin is allowed.
+type OriginValidator func(string) bool
+
+var (
+	defaultCorsOptionStatusCode = 200
+	defaultCorsMethods          = []string{"GET", "HEAD", "POST"}
+	defaultCorsHeaders          = []string{"Accept", "Accept-Language", "Content-Language", "Origin"}
+	// (WebKit/Safari v9 sends the Origin header by default in AJAX requests)
+)
+
+const (
+	corsOptionMethod           string = "OPTIONS"
+	corsAllowOriginHeader      string = "Access-Control-Allow-Origin"
+	corsExposeHeadersHeader    string = "Access-Control-Expose-Headers"
+	corsMaxAgeHeader           string = "Access-Control-Max-Age"
+	corsAllowMethodsHeader     string = "Access-Control-Allow-Methods"
+	corsAllowHeadersHeader     string = "Access-Control-Allow-Headers"
+	corsAllowCredentialsHeader string = "Access-Control-Allow-Credentials"
+	corsRequestMethodHeader    string = "Access-Control-Request-Method"
+	corsRequestHeadersHeader   string = "Access-Control-Request-Headers"
+	corsOriginHeader           string = "Origin"
+	corsVaryHeader             string = "Vary"
+	corsOriginMatchAll         string = "*"
+)
+
+func (ch *cors) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get(corsOriginHeader)
+	if !ch.isOriginAllowed(origin) {
+		if r.Method != corsOptionMethod || ch.ignoreOptions {
+			ch.h.ServeHTTP(w, r)
+			return
+		}
+
+		// A preflight request with a disallowed origin is short-circuited with
+		// the configured status rather than passed through, since the wrapped
+		// handler should never see a CORS preflight it can't fulfil.
+		w.Header().Add(corsVaryHeader, corsOriginHeader)
+		w.WriteHeader(ch.optionStatusCode)
+		return
+	}
+
+	if r.Method == corsOptionMethod {
+		if ch.ignoreOptions {
+			ch.h.ServeHTTP(w, r)
+			return
+		}
+
+		if _, ok := r.Header[corsRequestMethodHeader]; !ok {
+			ch.h.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add(corsVaryHeader, corsOriginHeader)
+		w.Header().Add(corsVaryHeader, corsRequestMethodHeader)
+		w.Header().Add(corsVaryHeader, corsRequestHeadersHeader)
+
+		requestedMethod := r.Header.Get(corsRequestMethodHeader)
+		if !ch.isMatch(requestedMethod, defaultCorsMethods) {
+			if !ch.isMatch(requestedMethod, ch.allowedMethods) {
+				w.WriteHeader(ch.optionStatusCode)
+				return
+			}
+		}
+
+		requestHeaders := strings.Split(r.Header.Get(corsRequestHeadersHeader), ",")
+		allowedHeaders := []string{}
+		for _, v := range requestHeaders {
+			canonicalHeader := http.CanonicalHeaderKey(strings.TrimSpace(v))
+			if canonicalHeader == "" || ch.isMatch(canonicalHeader, defaultCorsHeaders) {
+				continue
+			}
+
+			if !ch.isMatch(canonicalHeader, ch.allowedHeaders) {
+				w.WriteHeader(ch.optionStatusCode)
+				return
+			}
+
+			allowedHeaders = append(allowedHeaders, canonicalHeader)
+		}
+
+		if len(allowedHeaders) > 0 {
+			w.Header().Set(corsAllowHeadersHeader, strings.Join(allowedHeaders, ","))
+		}
+
+		if ch.maxAge > 0 {
+			w.Header().Set(corsMaxAgeHeader, strconv.Itoa(ch.maxAge))
+		}
+
+		if !ch.isMatch(requestedMethod, defaultCorsMethods) {
+			w.Header().Set(corsAllowMethodsHeader, requestedMethod)
+		} else {
+			w.Header().Set(corsAllowMethodsHeader, strings.Join(ch.allowedMethods, ","))
+		}
+
+		if origin == "" {
+			w.Header().Set(corsAllowOriginHeader, corsOriginMatchAll)
+		} else {
+			w.Header().Set(corsAllowOriginHeader, origin)
+		}
+
+		if ch.allowCredentials {
+			w.Header().Set(corsAllowCredentialsHeader, "true")
+		}
+
+		w.WriteHeader(ch.optionStatusCode)
+		return
+	}
+
+	w.Header().Add(corsVaryHeader, corsOriginHeader)
+
+	if origin == "" {
+		origin = corsOriginMatchAll
+	}
+	w.Header().Set(corsAllowOriginHeader, origin)
+
+	if len(ch.exposedHeaders) > 0 {
+		w.Header().Set(corsExposeHeadersHeader, strings.Join(ch.exposedHeaders, ","))
+	}
+
+	if ch.allowCredentials {
+		w.Header().Set(corsAllowCredentialsHeader, "true")
+	}
+
+	ch.h.ServeHTTP(w, r)
+}
+
+func (ch *cors) isOriginAllowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+
+	if ch.allowedOriginValidator != nil {
+		return ch.allowedOriginValidator(origin)
+	}
+
+	if len(ch.allowedOrigins) == 0 {
+		return true
+	}
+
+	for _, allowedOrigin := range ch.allowedOrigins {
+		if allowedOrigin == origin || allowedOrigin == corsOriginMatchAll {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (ch *cors) isMatch(needle string, haystack []string) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CORS provides Cross-Origin Resource Sharing middleware.
+// Example:
+//
+//	import (
+//	    "net/http"
+//
+//	    "github.com/stoneedgetech/handlers"
+//	)
+//
+//	func main() {
+//	    r := http.NewServeMux()
+//
+//	    // r is your router/handler that you want to wrap with the CORS middleware.
+//	    http.ListenAndServe(":8000", handlers.CORS()(r))
+//	}
+//
+// The above example uses the default CORS options: allow only GET, HEAD and
+// POST requests from any origin, no credentials, and no custom headers.
+func CORS(opts ...CORSOption) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		ch := parseCORSOptions(h, opts...)
+		return ch
+	}
+}
+
+func parseCORSOptions(h http.Handler, opts ...CORSOption) *cors {
+	ch := &cors{
+		h:                h,
+		allowedMethods:   defaultCorsMethods,
+		allowedHeaders:   defaultCorsHeaders,
+		optionStatusCode: defaultCorsOptionStatusCode,
+	}
+
+	for _, option := range opts {
+		option(ch)
+	}
+
+	return ch
+}
+
+// Functional Options
+
+// AllowedHeaders adds the provided headers to the list of allowed headers in a
+// CORS request. This is the list that will be used in the
+// Access-Control-Allow-Headers header.
+func AllowedHeaders(headers []string) CORSOption {
+	return func(ch *cors) {
+		for _, v := range headers {
+			normalizedHeader := http.CanonicalHeaderKey(strings.TrimSpace(v))
+			if normalizedHeader == "" {
+				continue
+			}
+
+			if !ch.isMatch(normalizedHeader, ch.allowedHeaders) {
+				ch.allowedHeaders = append(ch.allowedHeaders, normalizedHeader)
+			}
+		}
+	}
+}
+
+// AllowedMethods can be used to explicitly allow methods in the
+// Access-Control-Allow-Methods header. This overrides the default of
+// (GET, HEAD, POST).
+func AllowedMethods(methods []string) CORSOption {
+	return func(ch *cors) {
+		ch.allowedMethods = []string{}
+		for _, v := range methods {
+			normalizedMethod := strings.ToUpper(strings.TrimSpace(v))
+			if normalizedMethod == "" {
+				continue
+			}
+
+			if !ch.isMatch(normalizedMethod, ch.allowedMethods) {
+				ch.allowedMethods = append(ch.allowedMethods, normalizedMethod)
+			}
+		}
+	}
+}
+
+// AllowedOrigins sets the allowed origins for CORS requests, as used in the
+// 'Access-Control-Allow-Origin' HTTP header.
+//
+// Note: Passing in a []string{"*"} will allow any domain.
+func AllowedOrigins(origins []string) CORSOption {
+	return func(ch *cors) {
+		for _, v := range origins {
+			if v == corsOriginMatchAll {
+				ch.allowedOrigins = []string{corsOriginMatchAll}
+				return
+			}
+		}
+
+		ch.allowedOrigins = origins
+	}
+}
+
+// AllowedOriginValidator sets a function for evaluating allowed origins in CORS
+// requests, represented by the 'Access-Control-Allow-Origin' HTTP header.
+func AllowedOriginValidator(fn OriginValidator) CORSOption {
+	return func(ch *cors) {
+		ch.allowedOriginValidator = fn
+	}
+}
+
+// OptionStatusCode sets a custom status code on the OPTIONS requests.
+// Default behaviour sets the 200 status code.
+func OptionStatusCode(code int) CORSOption {
+	return func(ch *cors) {
+		ch.optionStatusCode = code
+	}
+}
+
+// ExposedHeaders can be used to specify headers that are available
+// and will not be stripped out by the user-agent.
+func ExposedHeaders(headers []string) CORSOption {
+	return func(ch *cors) {
+		for _, v := range headers {
+			normalizedHeader := http.CanonicalHeaderKey(strings.TrimSpace(v))
+			if normalizedHeader == "" {
+				continue
+			}
+
+			if !ch.isMatch(normalizedHeader, ch.exposedHeaders) {
+				ch.exposedHeaders = append(ch.exposedHeaders, normalizedHeader)
+			}
+		}
+	}
+}
+
+// AllowCredentials can be used to specify that the user agent may pass
+// authentication details along with the request.
+func AllowCredentials() CORSOption {
+	return func(ch *cors) {
+		ch.allowCredentials = true
+	}
+}
+
+// MaxAge determines the maximum age (in seconds) between preflight requests. A
+// maximum of 10 minutes is allowed. An age above this value will default to 10
+// minutes.
+func MaxAge(age int) CORSOption {
+	return func(ch *cors) {
+		// Maximum of 10 minutes.
+		if age > 600 {
+			age = 600
+		}
+
+		ch.maxAge = age
+	}
+}
+
+// IgnoreOptions causes the CORS middleware to ignore OPTIONS requests, instead
+// passing them through to the next handler. This is useful when your routing
+// already handles OPTIONS requests itself.
+func IgnoreOptions() CORSOption {
+	return func(ch *cors) {
+		ch.ignoreOptions = true
+	}
+}
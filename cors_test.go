@@ -0,0 +1,77 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSPreflightAllowedMethods(t *testing.T) {
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("wrapped handler should not be called for a preflight request")
+	})
+
+	tests := []struct {
+		name           string
+		allowedMethods []string
+		requestMethod  string
+		wantStatus     int
+		wantAllow      string
+	}{
+		{
+			name:          "default methods, requested method in defaults",
+			requestMethod: "GET",
+			wantStatus:    http.StatusOK,
+			wantAllow:     "GET,HEAD,POST",
+		},
+		{
+			name:          "default methods, requested method not in defaults or allowed",
+			requestMethod: "DELETE",
+			wantStatus:    defaultCorsOptionStatusCode,
+		},
+		{
+			name:           "configured methods, requested method allowed but not a default",
+			allowedMethods: []string{"GET", "PUT"},
+			requestMethod:  "PUT",
+			wantStatus:     http.StatusOK,
+			wantAllow:      "PUT",
+		},
+		{
+			name:           "configured methods, requested method not allowed",
+			allowedMethods: []string{"GET", "PUT"},
+			requestMethod:  "DELETE",
+			wantStatus:     defaultCorsOptionStatusCode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []CORSOption
+			if tt.allowedMethods != nil {
+				opts = append(opts, AllowedMethods(tt.allowedMethods))
+			}
+			h := CORS(opts...)(inner)
+
+			req := httptest.NewRequest(corsOptionMethod, "/", nil)
+			req.Header.Set(corsOriginHeader, "http://example.com")
+			req.Header.Set(corsRequestMethodHeader, tt.requestMethod)
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if tt.wantAllow != "" {
+				if got := rec.Header().Get(corsAllowMethodsHeader); got != tt.wantAllow {
+					t.Fatalf("%s = %q, want %q", corsAllowMethodsHeader, got, tt.wantAllow)
+				}
+			}
+		})
+	}
+}
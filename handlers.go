@@ -9,10 +9,7 @@ package handlers
 
 import (
 	"bufio"
-	"bytes"
-	"fmt"
 	"io"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"sort"
@@ -24,29 +21,13 @@ import (
 	"github.com/stoneedgetech/log"
 )
 
+// LogWrapper logs a debug-level dump of every request - method, URL, host,
+// headers, and body - before handing it to handlerToWrap. See
+// LogWrapperWithOptions for a version that bounds body capture and redacts
+// sensitive data; LogWrapper is equivalent to calling it with the zero
+// value of LogWrapperOptions.
 func LogWrapper(handlerToWrap http.HandlerFunc, logger *log.Log) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		formatStr := "\n%v %v%v %v\nHost: %v\nUser-Agent: %v\nContent-Length: %v\n%v\n%v"
-		var headerStr string
-		for headerName, headerValueStringSlice := range r.Header {
-			for _, headerValue := range headerValueStringSlice {
-				headerStr += fmt.Sprintf("%v: %v", headerName, headerValue)
-			}
-		}
-		bodyBytes, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			logger.Error("could not read request body")
-		} else {
-			var queryString string
-			if r.URL.RawQuery != "" {
-				queryString = "?" + r.URL.RawQuery
-			}
-			logger.Debug(fmt.Sprintf(formatStr, r.Method, r.URL.Path, queryString, r.Proto, r.Host, r.UserAgent(), r.ContentLength, headerStr, string(bodyBytes)))
-		}
-
-		r.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
-		handlerToWrap(w, r)
-	}
+	return LogWrapperWithOptions(handlerToWrap, logger, LogWrapperOptions{})
 }
 
 // MethodHandler is an http.Handler that dispatches to a handler whose key in the MethodHandler's
@@ -79,54 +60,65 @@ func (h MethodHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-// loggingHandler is the http.Handler implementation for LoggingHandlerTo and its friends
-type loggingHandler struct {
-	writer  io.Writer
-	handler http.Handler
+// ResponseInfo carries the metadata collected about a completed response
+// that is handed to a logFormatter (and, for JSON logging, to a caller's
+// enrichment callback) once the wrapped handler has returned.
+type ResponseInfo struct {
+	Start  time.Time
+	Status int
+	Size   int
 }
 
-// combinedLoggingHandler is the http.Handler implementation for LoggingHandlerTo and its friends
-type combinedLoggingHandler struct {
-	writer  io.Writer
-	handler http.Handler
+// Duration is how long the wrapped handler took to serve the request.
+func (info *ResponseInfo) Duration() time.Duration {
+	return time.Since(info.Start)
 }
 
-type forwardedLoggingHandler struct {
-	writer  io.Writer
-	handler http.Handler
+// logFormatter renders a completed request/response pair into a single log
+// entry's worth of bytes. Each logging handler variant (CLF, Combined,
+// Forwarded, JSON, ...) is just a different logFormatter plugged into
+// loggingCore.
+type logFormatter interface {
+	FormatLog(req *http.Request, info *ResponseInfo) []byte
 }
 
-type queueLoggingHandler struct {
-	logger  *log.Log
-	handler http.Handler
+// logSink is where a formatted log entry is written to. Most handlers write
+// to an io.Writer; QueueLoggingHandler and QueueJSONLoggingHandler instead
+// go through the module's *log.Log at debug level.
+type logSink interface {
+	WriteLog(entry []byte)
 }
 
-func (h loggingHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	t := time.Now()
-	var logger loggingResponseWriter
-	if _, ok := w.(http.Hijacker); ok {
-		logger = &hijackLogger{responseLogger: responseLogger{w: w}}
-	} else {
-		logger = &responseLogger{w: w}
-	}
-	h.handler.ServeHTTP(logger, req)
-	writeLog(h.writer, req, t, logger.Status(), logger.Size())
+type writerSink struct {
+	w io.Writer
 }
 
-func (h combinedLoggingHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	t := time.Now()
-	var logger loggingResponseWriter
-	if _, ok := w.(http.Hijacker); ok {
-		logger = &hijackLogger{responseLogger: responseLogger{w: w}}
-	} else {
-		logger = &responseLogger{w: w}
-	}
-	h.handler.ServeHTTP(logger, req)
-	writeCombinedLog(h.writer, req, t, logger.Status(), logger.Size())
+func (s writerSink) WriteLog(entry []byte) {
+	s.w.Write(entry)
+}
+
+type queueSink struct {
+	logger *log.Log
+}
+
+func (s queueSink) WriteLog(entry []byte) {
+	// The module's logger adds its own line framing, so the trailing
+	// newline that io.Writer-based sinks rely on would just show up as a
+	// blank line in the log.
+	s.logger.Debug(strings.TrimSuffix(string(entry), "\n"))
 }
 
-func (h forwardedLoggingHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	t := time.Now()
+// loggingCore is the http.Handler implementation shared by every access-log
+// handler in this package; it differs only in which logFormatter and
+// logSink it was built with.
+type loggingCore struct {
+	sink      logSink
+	formatter logFormatter
+	handler   http.Handler
+}
+
+func (h loggingCore) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	info := &ResponseInfo{Start: time.Now()}
 	var logger loggingResponseWriter
 	if _, ok := w.(http.Hijacker); ok {
 		logger = &hijackLogger{responseLogger: responseLogger{w: w}}
@@ -134,19 +126,48 @@ func (h forwardedLoggingHandler) ServeHTTP(w http.ResponseWriter, req *http.Requ
 		logger = &responseLogger{w: w}
 	}
 	h.handler.ServeHTTP(logger, req)
-	writeForwardedLog(h.writer, req, t, logger.Status(), logger.Size())
+	info.Status = logger.Status()
+	info.Size = logger.Size()
+	h.sink.WriteLog(h.formatter.FormatLog(req, info))
 }
 
-func (h queueLoggingHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	t := time.Now()
-	var logger loggingResponseWriter
-	if _, ok := w.(http.Hijacker); ok {
-		logger = &hijackLogger{responseLogger: responseLogger{w: w}}
-	} else {
-		logger = &responseLogger{w: w}
+type clfFormatter struct{}
+
+func (clfFormatter) FormatLog(req *http.Request, info *ResponseInfo) []byte {
+	buf := buildCommonLogLine(req, info.Start, info.Status, info.Size)
+	return append(buf, '\n')
+}
+
+type combinedFormatter struct{}
+
+func (combinedFormatter) FormatLog(req *http.Request, info *ResponseInfo) []byte {
+	buf := buildCommonLogLine(req, info.Start, info.Status, info.Size)
+	buf = append(buf, ` "`...)
+	buf = appendQuoted(buf, req.Referer())
+	buf = append(buf, `" "`...)
+	buf = appendQuoted(buf, req.UserAgent())
+	buf = append(buf, '"', '\n')
+	return buf
+}
+
+type forwardedFormatter struct{}
+
+// FormatLog writes a CLF line whose leading host field is req.RemoteAddr -
+// the true client address once ProxyHeaders has normalized it - followed
+// by the raw forwarding chain the proxies reported, for audit purposes.
+// These are deliberately different things: the former is what this
+// package trusts, the latter is what was actually presented on the wire.
+func (forwardedFormatter) FormatLog(req *http.Request, info *ResponseInfo) []byte {
+	buf := buildCommonLogLine(req, info.Start, info.Status, info.Size)
+	buf = append(buf, ' ')
+
+	chain := req.Header.Get("X-Forwarded-For")
+	if chain == "" {
+		chain = req.Header.Get("Forwarded")
 	}
-	h.handler.ServeHTTP(logger, req)
-	h.logger.Debug(string(buildCommonLogLine(req, t, logger.Status(), logger.Size())))
+	buf = append(buf, chain...)
+
+	return append(buf, '\n')
 }
 
 type loggingResponseWriter interface {
@@ -309,36 +330,6 @@ func buildCommonLogLine(req *http.Request, ts time.Time, status int, size int) [
 	return buf
 }
 
-// writeLog writes a log entry for req to w in Apache Common Log Format.
-// ts is the timestamp with which the entry should be logged.
-// status and size are used to provide the response HTTP status and size.
-func writeLog(w io.Writer, req *http.Request, ts time.Time, status, size int) {
-	buf := buildCommonLogLine(req, ts, status, size)
-	buf = append(buf, '\n')
-	w.Write(buf)
-}
-
-// writeCombinedLog writes a log entry for req to w in Apache Combined Log Format.
-// ts is the timestamp with which the entry should be logged.
-// status and size are used to provide the response HTTP status and size.
-func writeCombinedLog(w io.Writer, req *http.Request, ts time.Time, status, size int) {
-	buf := buildCommonLogLine(req, ts, status, size)
-	buf = append(buf, ` "`...)
-	buf = appendQuoted(buf, req.Referer())
-	buf = append(buf, `" "`...)
-	buf = appendQuoted(buf, req.UserAgent())
-	buf = append(buf, '"', '\n')
-	w.Write(buf)
-}
-
-func writeForwardedLog(w io.Writer, req *http.Request, ts time.Time, status, size int) {
-	buf := buildCommonLogLine(req, ts, status, size)
-	buf = append(buf, ' ')
-	buf = append(buf, req.Header.Get("X-Forwarded-For")...)
-	buf = append(buf, '\n')
-	w.Write(buf)
-}
-
 // CombinedLoggingHandler return a http.Handler that wraps h and logs requests to out in
 // Apache Combined Log Format.
 //
@@ -346,7 +337,7 @@ func writeForwardedLog(w io.Writer, req *http.Request, ts time.Time, status, siz
 //
 // LoggingHandler always sets the ident field of the log to -
 func CombinedLoggingHandler(out io.Writer, h http.Handler) http.Handler {
-	return combinedLoggingHandler{out, h}
+	return loggingCore{sink: writerSink{out}, formatter: combinedFormatter{}, handler: h}
 }
 
 // LoggingHandler return a http.Handler that wraps h and logs requests to out in
@@ -356,13 +347,13 @@ func CombinedLoggingHandler(out io.Writer, h http.Handler) http.Handler {
 //
 // LoggingHandler always sets the ident field of the log to -
 func LoggingHandler(out io.Writer, h http.Handler) http.Handler {
-	return loggingHandler{out, h}
+	return loggingCore{sink: writerSink{out}, formatter: clfFormatter{}, handler: h}
 }
 
 func ForwardedLoggingHandler(out io.Writer, h http.Handler) http.Handler {
-	return forwardedLoggingHandler{out, h}
+	return loggingCore{sink: writerSink{out}, formatter: forwardedFormatter{}, handler: h}
 }
 
 func QueueLoggingHandler(log *log.Log, h http.Handler) http.Handler {
-	return queueLoggingHandler{log, h}
+	return loggingCore{sink: queueSink{log}, formatter: clfFormatter{}, handler: h}
 }
@@ -0,0 +1,43 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestJSONFormatterFieldSelection(t *testing.T) {
+	f := newJSONFormatter(LoggingOptions{
+		Fields:       []string{"method", "status"},
+		StaticFields: map[string]interface{}{"service": "handlers"},
+	})
+
+	req := httptest.NewRequest("GET", "/foo", nil)
+	info := &ResponseInfo{Start: time.Now(), Status: 204, Size: 0}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(f.FormatLog(req, info), &entry); err != nil {
+		t.Fatalf("FormatLog produced invalid JSON: %v", err)
+	}
+
+	for _, unwanted := range []string{"uri", "proto", "duration_ms", "remote_addr"} {
+		if _, ok := entry[unwanted]; ok {
+			t.Errorf("entry contains %q, which wasn't requested in Fields", unwanted)
+		}
+	}
+
+	if entry["method"] != "GET" {
+		t.Errorf(`entry["method"] = %v, want "GET"`, entry["method"])
+	}
+	if entry["status"] != float64(204) {
+		t.Errorf(`entry["status"] = %v, want 204`, entry["status"])
+	}
+	if entry["service"] != "handlers" {
+		t.Errorf(`entry["service"] = %v, want "handlers"`, entry["service"])
+	}
+}
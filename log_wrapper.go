@@ -0,0 +1,237 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/stoneedgetech/log"
+)
+
+// defaultMaxBodyBytes bounds how much of a request body LogWrapper will
+// buffer for logging when LogWrapperOptions.MaxBodyBytes is left at zero.
+const defaultMaxBodyBytes = 64 * 1024
+
+const truncatedMarker = "...truncated"
+
+// LogWrapperOptions configures LogWrapperWithOptions.
+type LogWrapperOptions struct {
+	// MaxBodyBytes bounds how many bytes of the request body are buffered
+	// for logging. Bodies larger than this are logged with a trailing
+	// "...truncated" marker; the full body is still passed through to the
+	// wrapped handler unchanged. Defaults to 64KB.
+	MaxBodyBytes int64
+
+	// RedactHeaders lists header names (case-insensitive) whose values
+	// should be replaced with "***" in the logged output.
+	RedactHeaders []string
+
+	// RedactJSONFields lists JSON object keys that should be masked with
+	// "***" wherever they appear in a JSON request body, at any nesting
+	// depth, before it is logged. A body that is not valid JSON, including
+	// one cut short by MaxBodyBytes, is logged without any masking.
+	RedactJSONFields []string
+
+	// SkipContentTypes lists content types (eg. "multipart/form-data",
+	// "application/octet-stream") whose bodies should never be read for
+	// logging. A trailing "/*" matches any subtype. Matching requests are
+	// logged as "<N bytes elided>" (or "<unknown size elided>" if the
+	// Content-Length is not known) without the body ever being buffered.
+	SkipContentTypes []string
+}
+
+// LogWrapperWithOptions is LogWrapper with control over how much of the
+// request body is captured and which headers or JSON fields are redacted
+// before logging. Unlike the unbounded ioutil.ReadAll LogWrapper used to do
+// directly, this never buffers more than one byte past the MaxBodyBytes
+// limit, and the wrapped handler still sees the complete, unmodified body.
+func LogWrapperWithOptions(handlerToWrap http.HandlerFunc, logger *log.Log, opts LogWrapperOptions) http.HandlerFunc {
+	redactedHeaders := make(map[string]struct{}, len(opts.RedactHeaders))
+	for _, h := range opts.RedactHeaders {
+		redactedHeaders[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		formatStr := "\n%v %v%v %v\nHost: %v\nUser-Agent: %v\nContent-Length: %v\n%v\n%v"
+
+		headerStr := formatHeaders(r.Header, redactedHeaders)
+
+		bodyStr, newBody, err := captureRequestBody(r, opts)
+		if err != nil {
+			logger.Error("could not read request body")
+		} else {
+			var queryString string
+			if r.URL.RawQuery != "" {
+				queryString = "?" + r.URL.RawQuery
+			}
+			logger.Debug(fmt.Sprintf(formatStr, r.Method, r.URL.Path, queryString, r.Proto, r.Host, r.UserAgent(), r.ContentLength, headerStr, bodyStr))
+		}
+
+		r.Body = newBody
+		handlerToWrap(w, r)
+	}
+}
+
+// formatHeaders renders r.Header as "Name: value" lines, one per value, in
+// a deterministic (sorted by header name) order so that logged output is
+// diffable. Values for any header in redactedHeaders are replaced with "***".
+func formatHeaders(header http.Header, redactedHeaders map[string]struct{}) string {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		for _, value := range header[name] {
+			if _, redact := redactedHeaders[name]; redact {
+				value = "***"
+			}
+			fmt.Fprintf(&b, "%v: %v\n", name, value)
+		}
+	}
+
+	return b.String()
+}
+
+// captureRequestBody reads up to opts.MaxBodyBytes of r.Body for logging
+// and returns a replacement body that reproduces the original, complete
+// stream for the wrapped handler. Content types matching
+// opts.SkipContentTypes are never read.
+func captureRequestBody(r *http.Request, opts LogWrapperOptions) (string, io.ReadCloser, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return "", http.NoBody, nil
+	}
+
+	if matchesContentType(r.Header.Get("Content-Type"), opts.SkipContentTypes) {
+		size := "unknown size"
+		if r.ContentLength >= 0 {
+			size = fmt.Sprintf("%d bytes", r.ContentLength)
+		}
+		return fmt.Sprintf("<%s elided>", size), r.Body, nil
+	}
+
+	maxBytes := opts.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBodyBytes
+	}
+
+	// Read one byte past the limit so an exact-length body (len == maxBytes,
+	// nothing left unread) isn't mistaken for a truncated one. That extra
+	// byte is still included in newBody below - only the logged copy drops it.
+	//
+	// ReadAll returns whatever it managed to read even when it errors
+	// partway through, so newBody below still carries that partial prefix
+	// on error - the alternative, falling back to the live r.Body, would
+	// hand the wrapped handler a stream whose cursor has already advanced
+	// past bytes it can never get back.
+	captured, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBytes+1))
+
+	// The request body is only ever truncated in the logged copy: the
+	// wrapped handler still gets the captured prefix followed by whatever
+	// is left unread on the original body.
+	newBody := ioutil.NopCloser(io.MultiReader(bytes.NewReader(captured), r.Body))
+	if err != nil {
+		return "", newBody, err
+	}
+
+	// When truncated, logPortion is a copy, not a reslice, of captured
+	// (otherwise nothing is appended to it and it is captured itself): captured's backing
+	// array is still owned by the bytes.Reader inside newBody above, and
+	// appending the truncation marker in place would silently overwrite
+	// bytes the wrapped handler hasn't read yet.
+	truncated := int64(len(captured)) > maxBytes
+	logPortion := captured
+	if truncated {
+		logPortion = append([]byte(nil), captured[:maxBytes]...)
+	}
+
+	logBody := redactJSONFields(logPortion, opts.RedactJSONFields)
+	if truncated {
+		logBody = append(logBody, truncatedMarker...)
+	}
+
+	return string(logBody), newBody, nil
+}
+
+// matchesContentType reports whether contentType matches any of the given
+// patterns. A pattern ending in "/*" matches any subtype of that type.
+func matchesContentType(contentType string, patterns []string) bool {
+	if mediaType, _, ok := strings.Cut(contentType, ";"); ok {
+		contentType = mediaType
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if strings.HasSuffix(pattern, "/*") {
+			if strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+			continue
+		}
+
+		if contentType == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactJSONFields masks the named keys, wherever they occur at any
+// nesting depth, in a JSON body before it's logged. If body isn't valid
+// JSON, or no fields are configured, it is returned unchanged.
+func redactJSONFields(body []byte, fields []string) []byte {
+	if len(fields) == 0 {
+		return body
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return body
+	}
+
+	redact := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		redact[f] = struct{}{}
+	}
+
+	redacted, err := json.Marshal(maskJSONFields(parsed, redact))
+	if err != nil {
+		return body
+	}
+
+	return redacted
+}
+
+func maskJSONFields(v interface{}, redact map[string]struct{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			if _, ok := redact[k]; ok {
+				val[k] = "***"
+				continue
+			}
+			val[k] = maskJSONFields(child, redact)
+		}
+		return val
+	case []interface{}:
+		for i, child := range val {
+			val[i] = maskJSONFields(child, redact)
+		}
+		return val
+	default:
+		return val
+	}
+}
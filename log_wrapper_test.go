@@ -0,0 +1,126 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedactJSONFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		fields []string
+		want   string
+	}{
+		{
+			name:   "no fields configured",
+			body:   `{"password":"hunter2"}`,
+			fields: nil,
+			want:   `{"password":"hunter2"}`,
+		},
+		{
+			name:   "top-level field redacted",
+			body:   `{"password":"hunter2","user":"alice"}`,
+			fields: []string{"password"},
+			want:   `{"password":"***","user":"alice"}`,
+		},
+		{
+			name:   "nested field redacted",
+			body:   `{"auth":{"token":"secret"}}`,
+			fields: []string{"token"},
+			want:   `{"auth":{"token":"***"}}`,
+		},
+		{
+			name:   "invalid JSON returned unchanged",
+			body:   `not json`,
+			fields: []string{"password"},
+			want:   `not json`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(redactJSONFields([]byte(tt.body), tt.fields))
+			if got != tt.want {
+				t.Errorf("redactJSONFields(%q, %v) = %q, want %q", tt.body, tt.fields, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatHeadersRedacts(t *testing.T) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer secret")
+	header.Set("X-Request-Id", "abc123")
+
+	got := formatHeaders(header, map[string]struct{}{
+		http.CanonicalHeaderKey("Authorization"): {},
+	})
+
+	if !strings.Contains(got, "Authorization: ***") {
+		t.Errorf("expected Authorization to be redacted, got %q", got)
+	}
+	if !strings.Contains(got, "X-Request-Id: abc123") {
+		t.Errorf("expected X-Request-Id to pass through unredacted, got %q", got)
+	}
+}
+
+type errAfterNReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *errAfterNReader) Read(p []byte) (int, error) {
+	if r.pos >= len(r.data) {
+		return 0, errors.New("read failed")
+	}
+	n := copy(p, r.data[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+func TestCaptureRequestBodyPreservesPartialReadOnError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Body = io.NopCloser(&errAfterNReader{data: []byte("partial-body")})
+
+	_, newBody, err := captureRequestBody(req, LogWrapperOptions{})
+	if err == nil {
+		t.Fatal("expected an error from the failing reader")
+	}
+
+	// newBody chains the captured prefix followed by the now-exhausted,
+	// still-erroring original body, so reading it back out surfaces the
+	// same read error again after yielding the preserved bytes.
+	got, _ := io.ReadAll(newBody)
+	if string(got) != "partial-body" {
+		t.Errorf("newBody = %q, want the bytes read before the error to be preserved", got)
+	}
+}
+
+func TestCaptureRequestBodyTruncatesLoggedCopy(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789"))
+
+	logBody, newBody, err := captureRequestBody(req, LogWrapperOptions{MaxBodyBytes: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if logBody != "01234"+truncatedMarker {
+		t.Errorf("logBody = %q, want truncated marker appended", logBody)
+	}
+
+	full, readErr := io.ReadAll(newBody)
+	if readErr != nil {
+		t.Fatalf("unexpected error reading replacement body: %v", readErr)
+	}
+	if string(full) != "0123456789" {
+		t.Errorf("newBody = %q, want the full untruncated body passed to the wrapped handler", full)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+var xForwardedFor = http.CanonicalHeaderKey("X-Forwarded-For")
+var xForwardedHost = http.CanonicalHeaderKey("X-Forwarded-Host")
+var xForwardedProto = http.CanonicalHeaderKey("X-Forwarded-Proto")
+var xForwardedScheme = http.CanonicalHeaderKey("X-Forwarded-Scheme")
+var xRealIP = http.CanonicalHeaderKey("X-Real-IP")
+var forwarded = http.CanonicalHeaderKey("Forwarded")
+
+type proxyHeaders struct {
+	h              http.Handler
+	trustedProxies map[string]struct{}
+}
+
+// ProxyHeadersOption configures the ProxyHeaders middleware.
+type ProxyHeadersOption func(*proxyHeaders)
+
+// TrustedProxies restricts ProxyHeaders to honoring forwarding headers only
+// when they arrive from one of the given peer addresses. When no trusted
+// proxies are configured, headers from any peer are honored.
+func TrustedProxies(trustedProxies []string) ProxyHeadersOption {
+	return func(p *proxyHeaders) {
+		p.trustedProxies = make(map[string]struct{}, len(trustedProxies))
+		for _, addr := range trustedProxies {
+			p.trustedProxies[addr] = struct{}{}
+		}
+	}
+}
+
+// ProxyHeaders inspects common reverse-proxy headers (X-Forwarded-For,
+// X-Forwarded-Host, X-Forwarded-Proto, X-Real-IP, and the RFC 7239
+// Forwarded header) and rewrites r.RemoteAddr, r.Host, and r.URL.Scheme to
+// reflect the original client rather than the proxy, so that downstream
+// handlers (and the logging handlers in this package) see the true request.
+func ProxyHeaders(h http.Handler, opts ...ProxyHeadersOption) http.Handler {
+	p := &proxyHeaders{h: h}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+func (p *proxyHeaders) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p.isTrusted(r.RemoteAddr) {
+		if fwd := parseForwarded(r.Header.Get(forwarded)); fwd != nil {
+			if fwd.for_ != "" {
+				r.RemoteAddr = fwd.for_
+			}
+			if fwd.host != "" {
+				r.Host = fwd.host
+			}
+			if fwd.proto != "" {
+				r.URL.Scheme = fwd.proto
+			}
+		}
+
+		if fwdFor := r.Header.Get(xForwardedFor); fwdFor != "" {
+			if ip := leftmostIP(fwdFor); ip != "" {
+				r.RemoteAddr = ip
+			}
+		} else if realIP := r.Header.Get(xRealIP); realIP != "" {
+			r.RemoteAddr = realIP
+		}
+
+		if fwdHost := r.Header.Get(xForwardedHost); fwdHost != "" {
+			r.Host = fwdHost
+		}
+
+		if fwdProto := r.Header.Get(xForwardedProto); fwdProto != "" {
+			r.URL.Scheme = fwdProto
+		} else if fwdScheme := r.Header.Get(xForwardedScheme); fwdScheme != "" {
+			r.URL.Scheme = fwdScheme
+		}
+	}
+
+	p.h.ServeHTTP(w, r)
+}
+
+func (p *proxyHeaders) isTrusted(remoteAddr string) bool {
+	if len(p.trustedProxies) == 0 {
+		return true
+	}
+
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+
+	_, ok := p.trustedProxies[host]
+	return ok
+}
+
+// leftmostIP returns the leftmost non-private address in a comma-separated
+// X-Forwarded-For header value - ie. the first hop that isn't an internal
+// load balancer or proxy. If every hop looks private (or none parse as an
+// IP at all), it falls back to the first entry rather than dropping the
+// address.
+func leftmostIP(xff string) string {
+	parts := strings.Split(xff, ",")
+	for _, part := range parts {
+		candidate := strings.TrimSpace(part)
+		if candidate == "" {
+			continue
+		}
+
+		if isPrivateForwardedFor(candidate) {
+			continue
+		}
+
+		return candidate
+	}
+
+	return strings.TrimSpace(parts[0])
+}
+
+// isPrivateForwardedFor reports whether candidate - an X-Forwarded-For
+// entry, optionally bracketed IPv6 and/or carrying a port - names a
+// private, loopback, link-local, or unspecified address. Entries that
+// don't parse as an IP at all are treated as public, since there's nothing
+// to confidently filter out.
+func isPrivateForwardedFor(candidate string) bool {
+	host := normalizeForwardedFor(candidate)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	return ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+type forwardedElement struct {
+	for_  string
+	host  string
+	proto string
+}
+
+// parseForwarded parses the first element of an RFC 7239 Forwarded header,
+// e.g. `for=192.0.2.60;proto=https;by=203.0.113.43` or the quoted IPv6 form
+// `for="[2001:db8::1]:4711"`. It returns nil if the header is empty or
+// carries no recognized parameters.
+func parseForwarded(header string) *forwardedElement {
+	if header == "" {
+		return nil
+	}
+
+	// Only the first hop (closest to the client) is meaningful here; later
+	// hops describe intermediate proxies.
+	first := strings.Split(header, ",")[0]
+
+	var fwd forwardedElement
+	for _, pair := range strings.Split(first, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+
+		switch key {
+		case "for":
+			fwd.for_ = normalizeForwardedFor(value)
+		case "host":
+			fwd.host = value
+		case "proto":
+			fwd.proto = value
+		}
+	}
+
+	if fwd.for_ == "" && fwd.host == "" && fwd.proto == "" {
+		return nil
+	}
+
+	return &fwd
+}
+
+// normalizeForwardedFor unwraps a bracketed IPv6 "for" value, e.g.
+// "[2001:db8::1]:4711" or "[2001:db8::1]", into a bare address.
+func normalizeForwardedFor(value string) string {
+	if !strings.HasPrefix(value, "[") {
+		return value
+	}
+
+	if idx := strings.Index(value, "]"); idx != -1 {
+		return value[1:idx]
+	}
+
+	return value
+}
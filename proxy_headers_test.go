@@ -0,0 +1,70 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import "testing"
+
+func TestLeftmostIP(t *testing.T) {
+	tests := []struct {
+		xff  string
+		want string
+	}{
+		{"203.0.113.9", "203.0.113.9"},
+		{"10.0.0.1, 203.0.113.9", "203.0.113.9"},
+		{"203.0.113.9, 10.0.0.1", "203.0.113.9"},
+		{"10.0.0.1, 192.168.1.1", "10.0.0.1"},
+		{"127.0.0.1, 203.0.113.9", "203.0.113.9"},
+		{"[2001:db8::1]:4711, 10.0.0.1", "[2001:db8::1]:4711"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.xff, func(t *testing.T) {
+			if got := leftmostIP(tt.xff); got != tt.want {
+				t.Errorf("leftmostIP(%q) = %q, want %q", tt.xff, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseForwarded(t *testing.T) {
+	tests := []struct {
+		header string
+		want   *forwardedElement
+	}{
+		{"", nil},
+		{
+			`for=192.0.2.60;proto=https;by=203.0.113.43`,
+			&forwardedElement{for_: "192.0.2.60", proto: "https"},
+		},
+		{
+			`for="[2001:db8::1]:4711"`,
+			&forwardedElement{for_: "2001:db8::1"},
+		},
+		{
+			`for=192.0.2.60, for=198.51.100.17`,
+			&forwardedElement{for_: "192.0.2.60"},
+		},
+		{
+			`host=example.com`,
+			&forwardedElement{host: "example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got := parseForwarded(tt.header)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("parseForwarded(%q) = %+v, want nil", tt.header, got)
+				}
+				return
+			}
+
+			if got == nil || *got != *tt.want {
+				t.Fatalf("parseForwarded(%q) = %+v, want %+v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
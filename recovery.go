@@ -0,0 +1,156 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+	"runtime"
+)
+
+// RecoveryHandlerLogger is an interface used by the recovering handler to
+// log the recovered panic (and, if enabled, the stack trace). It is
+// satisfied by this module's *log.Log, whose Error method has this exact
+// signature.
+type RecoveryHandlerLogger interface {
+	Error(...interface{})
+}
+
+type recoveryHandler struct {
+	handler    http.Handler
+	logger     RecoveryHandlerLogger
+	printStack bool
+}
+
+// RecoveryOption provides a functional approach to define configuration for
+// a handler; such as setting the logger or whether or not to print stack
+// traces on panic.
+type RecoveryOption func(*recoveryHandler)
+
+func parseRecoveryOptions(h http.Handler, opts ...RecoveryOption) *recoveryHandler {
+	r := &recoveryHandler{handler: h}
+	for _, option := range opts {
+		option(r)
+	}
+
+	return r
+}
+
+// RecoveryHandler is HTTP middleware that recovers from a panic, logs the
+// panic (and a stack trace, when enabled with PrintRecoveryStack(true)),
+// and writes http.StatusInternalServerError if the response hasn't already
+// been written to.
+//
+// Example:
+//
+//	r := mux.NewRouter()
+//	r.HandleFunc("/", YourHandler)
+//
+//	http.ListenAndServe(":1123", handlers.RecoveryHandler()(r))
+func RecoveryHandler(opts ...RecoveryOption) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return parseRecoveryOptions(h, opts...)
+	}
+}
+
+// RecoveryLogger is a functional option to override the default logger used
+// by RecoveryHandler, which otherwise logs to the standard library's log
+// package. This module's *log.Log satisfies RecoveryHandlerLogger, so it can
+// be passed in directly.
+func RecoveryLogger(logger RecoveryHandlerLogger) RecoveryOption {
+	return func(r *recoveryHandler) {
+		r.logger = logger
+	}
+}
+
+// PrintRecoveryStack is a functional option to enable or disable including a
+// full runtime.Stack dump alongside the recovered panic value.
+func PrintRecoveryStack(shouldPrint bool) RecoveryOption {
+	return func(r *recoveryHandler) {
+		r.printStack = shouldPrint
+	}
+}
+
+// hijackTrackingWriter notes whether Hijack was ever called successfully,
+// so recoveryHandler can tell - regardless of what other middleware, if
+// any, sits between it and the underlying connection - whether there's
+// still an HTTP response left to write after a panic. It's only used to
+// wrap writers that already implement http.Hijacker, mirroring this
+// package's hijackLogger, so it never advertises Hijack support the
+// underlying writer doesn't actually have.
+type hijackTrackingWriter struct {
+	http.ResponseWriter
+	hijacked bool
+}
+
+func (hw *hijackTrackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := hw.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		hw.hijacked = true
+	}
+
+	return conn, rw, err
+}
+
+func (h *recoveryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var hw *hijackTrackingWriter
+	if _, ok := w.(http.Hijacker); ok {
+		hw = &hijackTrackingWriter{ResponseWriter: w}
+		w = hw
+	}
+
+	defer h.recover(w, hw, req)
+
+	h.handler.ServeHTTP(w, req)
+}
+
+func (h *recoveryHandler) recover(w http.ResponseWriter, hw *hijackTrackingWriter, req *http.Request) {
+	err := recover()
+	if err == nil {
+		return
+	}
+
+	// If the connection has already been hijacked (eg. a websocket
+	// upgrade), there's no HTTP response left for us to write; re-raise so
+	// whatever owns the hijacked connection - or the process - handles it.
+	if hw != nil && hw.hijacked {
+		panic(err)
+	}
+
+	w.WriteHeader(http.StatusInternalServerError)
+	h.log(err)
+}
+
+func (h *recoveryHandler) log(v interface{}) {
+	if h.logger != nil {
+		h.logger.Error(v)
+	} else {
+		log.Println(v)
+	}
+
+	if h.printStack {
+		h.logStack()
+	}
+}
+
+func (h *recoveryHandler) logStack() {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	if h.logger != nil {
+		h.logger.Error(string(buf))
+	} else {
+		log.Println(string(buf))
+	}
+}
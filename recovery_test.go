@@ -0,0 +1,38 @@
+// Copyright 2013 The Gorilla Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stoneedgetech/log"
+)
+
+func TestRecoveryLoggerAcceptsModuleLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New(&out, log.Error)
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	h := RecoveryHandler(RecoveryLogger(logger))(panicking)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+
+	if !strings.Contains(out.String(), "boom") {
+		t.Fatalf("expected the module logger to have received the panic value, got %q", out.String())
+	}
+}